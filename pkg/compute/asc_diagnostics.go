@@ -0,0 +1,70 @@
+package compute
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/fastly/cli/pkg/text"
+)
+
+// ascDiagnosticRegexp matches the diagnostic format emitted by the asc
+// compiler, e.g.:
+//
+//	ERROR TS2345: Argument of type 'string' is not assignable to parameter of type 'i32' in src/index.ts(12,34)
+var ascDiagnosticRegexp = regexp.MustCompile(`^(ERROR|WARNING) (TS\d+): (.+) in (.+)\((\d+),(\d+)\)$`)
+
+// diagnosticWriter wraps an io.Writer, line-buffering asc's output and
+// re-rendering any recognised `ERROR`/`WARNING` diagnostics via pkg/text so
+// the file/line they refer to stands out. Unrecognised lines are passed
+// through unchanged.
+type diagnosticWriter struct {
+	out io.Writer
+	buf bytes.Buffer
+}
+
+// newDiagnosticWriter returns a diagnosticWriter that forwards to out.
+func newDiagnosticWriter(out io.Writer) *diagnosticWriter {
+	return &diagnosticWriter{out: out}
+}
+
+// Write implements io.Writer.
+func (w *diagnosticWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+
+	data := w.buf.Bytes()
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			break
+		}
+		w.writeLine(string(data[:idx]))
+		data = data[idx+1:]
+	}
+	w.buf = *bytes.NewBuffer(append([]byte(nil), data...))
+
+	return len(p), nil
+}
+
+// Flush writes out any buffered partial line, and should be called once the
+// underlying command has exited.
+func (w *diagnosticWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.writeLine(w.buf.String())
+		w.buf.Reset()
+	}
+}
+
+func (w *diagnosticWriter) writeLine(line string) {
+	line = strings.TrimRight(line, "\r")
+
+	if m := ascDiagnosticRegexp.FindStringSubmatch(line); m != nil {
+		level, code, message, file, row, col := m[1], m[2], m[3], m[4], m[5], m[6]
+		fmt.Fprintf(w.out, "%s %s: %s\n\t%s\n", level, text.Bold(code), message, text.Bold(fmt.Sprintf("%s:%s:%s", file, row, col)))
+		return
+	}
+
+	fmt.Fprintln(w.out, line)
+}
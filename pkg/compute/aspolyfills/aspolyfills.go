@@ -0,0 +1,27 @@
+// Package aspolyfills ships AssemblyScript stand-ins for Node.js built-in
+// modules that the Compute@Edge Wasm runtime cannot provide, so that more of
+// the npm ecosystem compiles out of the box. Some are genuine polyfills
+// (path, url, querystring); others are no-op stubs that panic if actually
+// called (fs, net, child_process), turning a cryptic asc link error into an
+// actionable runtime message.
+package aspolyfills
+
+import _ "embed"
+
+//go:embed path.ts
+var Path string
+
+//go:embed url.ts
+var URL string
+
+//go:embed querystring.ts
+var QueryString string
+
+//go:embed fs.ts
+var FS string
+
+//go:embed net.ts
+var Net string
+
+//go:embed child_process.ts
+var ChildProcess string
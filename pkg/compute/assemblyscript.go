@@ -6,7 +6,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 
 	"github.com/fastly/cli/pkg/common"
 	"github.com/fastly/cli/pkg/errors"
@@ -14,7 +13,18 @@ import (
 )
 
 // AssemblyScript implements Toolchain for the AssemblyScript language.
-type AssemblyScript struct{}
+type AssemblyScript struct {
+	// PackageManager optionally overrides package manager auto-detection.
+	// It's sourced from the `package_manager` field under
+	// `[language.assemblyscript]` in fastly.toml. When empty, the package
+	// manager is inferred from the lockfile present in $PWD, falling back
+	// to npm.
+	PackageManager string
+
+	// Offline disables auto-provisioning of a missing/incompatible Node.js
+	// toolchain; Verify and Build fail with remediation instead.
+	Offline bool
+}
 
 // Name implements the Toolchain interface and returns the name of the toolchain.
 func (a AssemblyScript) Name() string { return "assemblyscript" }
@@ -48,23 +58,47 @@ func (a AssemblyScript) IncludeFiles() []string {
 // Verify implements the Toolchain interface and verifies whether the
 // AssemblyScript language toolchain is correctly configured on the host.
 func (a AssemblyScript) Verify(out io.Writer) error {
-	// 1) Check `npm` is on $PATH
+	// 0) Resolve the required Node.js toolchain
+	//
+	// Rather than just failing with a remediation string when the installed
+	// Node.js doesn't match what the project requires, resolve (and, unless
+	// offline, provision) a compatible version from the CLI's toolchain
+	// cache, and prefer it on $PATH for the remainder of this process.
+	constraints, err := ReadToolchainConstraints()
+	if err != nil {
+		return err
+	}
+	binDir, err := ResolveToolchain(out, constraints, a.Offline)
+	if err != nil {
+		return err
+	}
+	if binDir != "" {
+		prependPath(binDir)
+	}
+
+	// 1) Determine the package manager and check it's on $PATH
 	//
-	// npm is Node/AssemblyScript's toolchain installer and manager, it is
-	// needed to assert that the correct versions of the asc compiler and
-	// @fastly/as-compute package are installed. We only check whether the
-	// binary exists on the users $PATH and error with installation help text.
-	fmt.Fprintf(out, "Checking if npm is installed...\n")
+	// The package manager is Node/AssemblyScript's toolchain installer and
+	// manager, it is needed to assert that the correct versions of the asc
+	// compiler and @fastly/as-compute package are installed. We only check
+	// whether the binary exists on the users $PATH and error with
+	// installation help text.
+	pm, err := DeterminePackageManager(a.PackageManager)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Checking if %s is installed...\n", pm.Name())
 
-	p, err := exec.LookPath("npm")
+	p, err := exec.LookPath(pm.Name())
 	if err != nil {
 		return errors.RemediationError{
-			Inner:       fmt.Errorf("`npm` not found in $PATH"),
+			Inner:       fmt.Errorf("`%s` not found in $PATH", pm.Name()),
 			Remediation: fmt.Sprintf("To fix this error, install Node.js and npm by visiting:\n\n\t$ %s", text.Bold("https://nodejs.org/")),
 		}
 	}
 
-	fmt.Fprintf(out, "Found npm at %s\n", p)
+	fmt.Fprintf(out, "Found %s at %s\n", pm.Name(), p)
 
 	// 2) Check package.json file exists in $PWD
 	//
@@ -88,19 +122,24 @@ func (a AssemblyScript) Verify(out io.Writer) error {
 	// 3) Check if `asc` is installed.
 	//
 	// asc is the AssemblyScript compiler. We first check if it exists in the
-	// package.json and then whether the binary exists in the npm bin directory.
+	// package.json and then whether the binary exists in the package
+	// manager's bin directory.
 	fmt.Fprintf(out, "Checking if AssemblyScript is installed...\n")
-	if !checkPackageDependencyExists("assemblyscript") {
+	exists, err := packageDependencyExists("assemblyscript")
+	if err != nil {
+		return fmt.Errorf("checking package.json dependencies: %w", err)
+	}
+	if !exists {
 		return errors.RemediationError{
 			Inner:       fmt.Errorf("`assemblyscript` not found in package.json"),
 			Remediation: fmt.Sprintf("To fix this error, run the following command:\n\n\t$ %s", text.Bold("npm install --save-dev assemblyscript")),
 		}
 	}
 
-	p, err = getNpmBinPath()
+	p, err = pm.BinPath()
 	if err != nil {
 		return errors.RemediationError{
-			Inner:       fmt.Errorf("could not determine npm bin path"),
+			Inner:       fmt.Errorf("could not determine %s bin path: %w", pm.Name(), err),
 			Remediation: fmt.Sprintf("To fix this error, run the following command:\n\n\t$ %s", text.Bold("npm install --global npm@latest")),
 		}
 	}
@@ -118,28 +157,37 @@ func (a AssemblyScript) Verify(out io.Writer) error {
 
 	fmt.Fprintf(out, "Found asc at %s\n", path)
 
+	if err := CheckAscVersion(path, constraints); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // Initialize implements the Toolchain interface and initializes a newly cloned
 // package by installing required dependencies.
-func (a AssemblyScript) Initialize(out io.Writer) error {
-	// 1) Check `npm` is on $PATH
+func (a AssemblyScript) Initialize(out io.Writer, verbose bool, opts InitializeOptions) error {
+	// 1) Determine the package manager and check it's on $PATH
 	//
-	// npm is Node/AssemblyScript's toolchain package manager, it is needed to
-	// install the package dependencies on initialization. We only check whether
-	// the binary exists on the users $PATH and error with installation help text.
-	fmt.Fprintf(out, "Checking if npm is installed...\n")
+	// The package manager is needed to install the package dependencies on
+	// initialization. We only check whether the binary exists on the users
+	// $PATH and error with installation help text.
+	pm, err := DeterminePackageManager(a.PackageManager)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Checking if %s is installed...\n", pm.Name())
 
-	p, err := exec.LookPath("npm")
+	p, err := exec.LookPath(pm.Name())
 	if err != nil {
 		return errors.RemediationError{
-			Inner:       fmt.Errorf("`npm` not found in $PATH"),
+			Inner:       fmt.Errorf("`%s` not found in $PATH", pm.Name()),
 			Remediation: fmt.Sprintf("To fix this error, install Node.js and npm by visiting:\n\n\t$ %s", text.Bold("https://nodejs.org/")),
 		}
 	}
 
-	fmt.Fprintf(out, "Found npm at %s\n", p)
+	fmt.Fprintf(out, "Found %s at %s\n", pm.Name(), p)
 
 	// 2) Check package.json file exists in $PWD
 	//
@@ -159,9 +207,8 @@ func (a AssemblyScript) Initialize(out io.Writer) error {
 
 	fmt.Fprintf(out, "Found package.json at %s\n", fpath)
 
-	// Call npm install.
-	cmd := common.NewStreamingExec("npm", []string{"install"}, []string{}, false, out)
-	if err := cmd.Exec(); err != nil {
+	// Install dependencies using the resolved package manager.
+	if err := pm.Install(out, verbose, opts); err != nil {
 		return err
 	}
 
@@ -170,7 +217,21 @@ func (a AssemblyScript) Initialize(out io.Writer) error {
 
 // Build implements the Toolchain interface and attempts to compile the package
 // AssemblyScript source to a Wasm binary.
-func (a AssemblyScript) Build(out io.Writer, verbose bool) error {
+func (a AssemblyScript) Build(out io.Writer, verbose bool, opts InitializeOptions) error {
+	// Resolve the required Node.js toolchain, preferring it on $PATH, before
+	// resolving the package manager's asc path below.
+	constraints, err := ReadToolchainConstraints()
+	if err != nil {
+		return err
+	}
+	nodeBinDir, err := ResolveToolchain(out, constraints, a.Offline)
+	if err != nil {
+		return err
+	}
+	if nodeBinDir != "" {
+		prependPath(nodeBinDir)
+	}
+
 	// Check if bin directory exists and create if not.
 	pwd, err := os.Getwd()
 	if err != nil {
@@ -181,10 +242,40 @@ func (a AssemblyScript) Build(out io.Writer, verbose bool) error {
 		return fmt.Errorf("error making bin directory: %w", err)
 	}
 
-	npmdir, err := getNpmBinPath()
+	pm, err := DeterminePackageManager(a.PackageManager)
+	if err != nil {
+		return err
+	}
+
+	// Re-install dependencies only when --production/--frozen were actually
+	// requested; Build otherwise trusts the dependencies Initialize already
+	// installed rather than reinstalling (and hitting the registry) on
+	// every single build.
+	if opts.Production || opts.Frozen {
+		if err := pm.Install(out, verbose, opts); err != nil {
+			return err
+		}
+	}
+
+	npmdir, err := pm.BinPath()
+	if err != nil {
+		return err
+	}
+
+	if err := CheckAscVersion(filepath.Join(npmdir, "asc"), constraints); err != nil {
+		return err
+	}
+
+	// Check for imports of Node.js built-ins the Wasm runtime can't provide,
+	// failing fast with a clear message, and provision AssemblyScript
+	// polyfills for the ones we can stand in for.
+	neededPolyfills, err := checkNodeBuiltinImports(a.SourceDirectory())
 	if err != nil {
 		return err
 	}
+	if err := provisionPolyfills(neededPolyfills); err != nil {
+		return err
+	}
 
 	args := []string{
 		"src/index.ts",
@@ -197,34 +288,15 @@ func (a AssemblyScript) Build(out io.Writer, verbose bool) error {
 		args = append(args, "--verbose")
 	}
 
-	// Call asc with the build arguments.
-	cmd := common.NewStreamingExec(filepath.Join(npmdir, "asc"), args, []string{}, verbose, out)
-	if err := cmd.Exec(); err != nil {
+	// Call asc with the build arguments, rendering any ERROR/WARNING
+	// diagnostics it emits with their file/line highlighted.
+	dw := newDiagnosticWriter(out)
+	cmd := common.NewStreamingExec(filepath.Join(npmdir, "asc"), args, []string{}, verbose, dw)
+	err = cmd.Exec()
+	dw.Flush()
+	if err != nil {
 		return err
 	}
 
 	return nil
 }
-
-func getNpmBinPath() (string, error) {
-	path, err := exec.Command("npm", "bin").Output()
-	if err != nil {
-		return "", fmt.Errorf("error getting npm bin path: %w", err)
-	}
-	return strings.TrimSpace(string(path)), nil
-}
-
-func checkPackageDependencyExists(name string) bool {
-	// gosec flagged this:
-	// G204 (CWE-78): Subprocess launched with variable
-	// Disabling as the variables come from trusted sources.
-	/* #nosec */
-	cmd := exec.Command("npm", "link", "--json", "--depth", "0", name)
-	if err := cmd.Start(); err != nil {
-		return false
-	}
-	if err := cmd.Wait(); err != nil {
-		return false
-	}
-	return true
-}
@@ -0,0 +1,184 @@
+package compute
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/errors"
+
+	"github.com/fastly/cli/pkg/compute/aspolyfills"
+)
+
+// nodeBuiltin describes how an AssemblyScript import of a Node.js built-in
+// module should be handled. Source is the aspolyfills module to provide in
+// its place; it's empty for built-ins with no usable polyfill, in which
+// case the import fails the build instead.
+type nodeBuiltin struct {
+	Source string
+}
+
+// nodeBuiltins is the single curated table of Node.js built-in modules the
+// Compute@Edge Wasm runtime cannot provide natively. Extend this table (and
+// aspolyfills, if adding a Source) as the Wasm runtime grows to support more
+// of the npm ecosystem.
+var nodeBuiltins = map[string]nodeBuiltin{
+	"path":          {Source: aspolyfills.Path},
+	"url":           {Source: aspolyfills.URL},
+	"querystring":   {Source: aspolyfills.QueryString},
+	"fs":            {Source: aspolyfills.FS},
+	"net":           {Source: aspolyfills.Net},
+	"child_process": {Source: aspolyfills.ChildProcess},
+	"crypto":        {},
+	"buffer":        {},
+	"os":            {},
+	"http":          {},
+	"https":         {},
+	"stream":        {},
+	"events":        {},
+	"util":          {},
+	"zlib":          {},
+	"dns":           {},
+	"tls":           {},
+	"process":       {},
+	"module":        {},
+	"assert":        {},
+}
+
+// importRegexp matches the module specifier of both `import ... from "mod"`
+// and bare `import "mod"` statements.
+var importRegexp = regexp.MustCompile(`(?:from|import)\s+['"]([^'"]+)['"]`)
+
+// nodeBuiltinImport records a single import of a Node.js built-in module,
+// for use in RemediationError messages.
+type nodeBuiltinImport struct {
+	Module string
+	File   string
+}
+
+// nodeModulesDir is the directory third-party AssemblyScript packages are
+// installed into, which scanNodeBuiltinImports also walks: a dependency
+// pulling in a Node.js built-in is just as fatal to the Wasm build as the
+// project's own source doing so.
+const nodeModulesDir = "node_modules"
+
+// scanNodeBuiltinImports walks srcDir and node_modules for .ts source files
+// and returns every import of a module in nodeBuiltins, split into those
+// with a usable polyfill and those without.
+func scanNodeBuiltinImports(srcDir string) (polyfilled, unsupported []nodeBuiltinImport, err error) {
+	for _, root := range []string{srcDir, nodeModulesDir} {
+		if !common.FileExists(root) {
+			continue
+		}
+
+		walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				// Skip the polyfill packages we provision ourselves under
+				// node_modules/<module>/: they stand in for a built-in, not
+				// an import of one.
+				if root == nodeModulesDir {
+					if rel, relErr := filepath.Rel(root, path); relErr == nil {
+						if b, ok := nodeBuiltins[rel]; ok && b.Source != "" {
+							return filepath.SkipDir
+						}
+					}
+				}
+				return nil
+			}
+			if filepath.Ext(path) != ".ts" {
+				return nil
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", path, err)
+			}
+
+			for _, match := range importRegexp.FindAllStringSubmatch(string(data), -1) {
+				module := match[1]
+				builtin, ok := nodeBuiltins[module]
+				if !ok {
+					continue
+				}
+				imp := nodeBuiltinImport{Module: module, File: path}
+				if builtin.Source != "" {
+					polyfilled = append(polyfilled, imp)
+				} else {
+					unsupported = append(unsupported, imp)
+				}
+			}
+
+			return nil
+		})
+		if walkErr != nil {
+			return nil, nil, walkErr
+		}
+	}
+
+	return polyfilled, unsupported, nil
+}
+
+// checkNodeBuiltinImports scans srcDir and node_modules for imports of
+// unsupported Node.js built-ins — including ones pulled in transitively by a
+// third-party dependency — and, if any are found, returns a RemediationError
+// listing them. It also returns the distinct set of built-ins that need a
+// polyfill provisioned via provisionPolyfills.
+func checkNodeBuiltinImports(srcDir string) ([]string, error) {
+	polyfilled, unsupported, err := scanNodeBuiltinImports(srcDir)
+	if err != nil {
+		return nil, fmt.Errorf("scanning %s and %s for Node.js built-in imports: %w", srcDir, nodeModulesDir, err)
+	}
+
+	if len(unsupported) > 0 {
+		var b strings.Builder
+		for _, imp := range unsupported {
+			fmt.Fprintf(&b, "\n\t%s imports %q", imp.File, imp.Module)
+		}
+		return nil, errors.RemediationError{
+			Inner:       fmt.Errorf("unsupported Node.js built-in module(s) imported:%s", b.String()),
+			Remediation: "To fix this error, remove the dependency on the Node.js built-in(s) listed above, or vendor an AssemblyScript-compatible alternative.",
+		}
+	}
+
+	seen := map[string]struct{}{}
+	var needed []string
+	for _, imp := range polyfilled {
+		if _, ok := seen[imp.Module]; ok {
+			continue
+		}
+		seen[imp.Module] = struct{}{}
+		needed = append(needed, imp.Module)
+	}
+
+	return needed, nil
+}
+
+// provisionPolyfills writes the aspolyfills source for each named built-in
+// module into node_modules/<module>/, so that asc's module resolution finds
+// it in place of the (unavailable) Node.js built-in.
+func provisionPolyfills(modules []string) error {
+	for _, module := range modules {
+		dir := filepath.Join("node_modules", module)
+		if err := common.MakeDirectoryIfNotExists(dir); err != nil {
+			return fmt.Errorf("provisioning polyfill for %q: %w", module, err)
+		}
+
+		pkgJSON := fmt.Sprintf("{\n  \"name\": %q,\n  \"main\": \"index.ts\"\n}\n", module)
+		if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(pkgJSON), 0o644); err != nil {
+			return fmt.Errorf("provisioning polyfill for %q: %w", module, err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "index.ts"), []byte(nodeBuiltins[module].Source), 0o644); err != nil {
+			return fmt.Errorf("provisioning polyfill for %q: %w", module, err)
+		}
+	}
+	return nil
+}
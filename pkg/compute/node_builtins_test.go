@@ -0,0 +1,85 @@
+package compute
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestScanNodeBuiltinImports asserts that scanNodeBuiltinImports finds
+// imports of Node.js built-ins in both the project's own source and
+// node_modules (so third-party dependencies are caught too), while
+// skipping the polyfill packages provisioned under node_modules for
+// built-ins that have one.
+func TestScanNodeBuiltinImports(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(t, filepath.Join(dir, "src", "index.ts"), `
+import { join } from "path";
+import { randomBytes } from "crypto";
+`)
+	writeTestFile(t, filepath.Join(dir, "node_modules", "some-pkg", "index.ts"), `
+import { createServer } from "net";
+`)
+	// A previously provisioned polyfill package: its own source must not be
+	// scanned for imports of the built-in it stands in for.
+	writeTestFile(t, filepath.Join(dir, "node_modules", "path", "index.ts"), `
+import { createServer } from "net";
+`)
+
+	oldWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getting working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("changing to temp dir: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(oldWd); err != nil {
+			t.Fatalf("restoring working directory: %v", err)
+		}
+	})
+
+	polyfilled, unsupported, err := scanNodeBuiltinImports("src")
+	if err != nil {
+		t.Fatalf("scanNodeBuiltinImports: %v", err)
+	}
+
+	wantPolyfilled := map[string]bool{"path": false, "net": false}
+	for _, imp := range polyfilled {
+		if _, ok := wantPolyfilled[imp.Module]; !ok {
+			t.Errorf("unexpected polyfilled import of %q in %s", imp.Module, imp.File)
+			continue
+		}
+		wantPolyfilled[imp.Module] = true
+	}
+	for module, found := range wantPolyfilled {
+		if !found {
+			t.Errorf("expected a polyfilled import of %q, found none", module)
+		}
+	}
+
+	if len(unsupported) != 1 || unsupported[0].Module != "crypto" {
+		t.Errorf("got unsupported imports %+v, want a single import of crypto", unsupported)
+	}
+
+	// The "net" import inside node_modules/some-pkg is the only one that
+	// should surface, not the one inside the node_modules/path polyfill
+	// package itself.
+	for _, imp := range polyfilled {
+		if imp.Module == "net" && filepath.Dir(imp.File) != filepath.Join("node_modules", "some-pkg") {
+			t.Errorf("unexpected source of net import: %s", imp.File)
+		}
+	}
+}
+
+// writeTestFile writes contents to path, creating any parent directories.
+func writeTestFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("making directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
@@ -0,0 +1,56 @@
+package compute
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/errors"
+)
+
+// NPM implements PackageManager for npm.
+type NPM struct{}
+
+// Name implements the PackageManager interface.
+func (n NPM) Name() string { return "npm" }
+
+// Install implements the PackageManager interface.
+func (n NPM) Install(out io.Writer, verbose bool, opts InitializeOptions) error {
+	args := []string{"install"}
+	if opts.Frozen {
+		args = []string{"ci"}
+	}
+	if opts.Production {
+		args = append(args, "--production")
+	}
+
+	debugf(out, verbose, "running `npm %s`", strings.Join(args, " "))
+
+	var buf bytes.Buffer
+	cmd := common.NewStreamingExec("npm", args, []string{}, false, io.MultiWriter(out, &buf))
+	if err := cmd.Exec(); err != nil {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("npm install failed: %w", wrapExecError(err, buf.String())),
+			Remediation: "To fix this error, check the npm output above for the underlying cause (e.g. a network failure, registry permissions, or a broken package.json), then re-run the command.",
+		}
+	}
+	return nil
+}
+
+// BinPath implements the PackageManager interface.
+func (n NPM) BinPath() (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("npm", "bin")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.RemediationError{
+			Inner:       fmt.Errorf("error getting npm bin path: %w", wrapExecError(err, stderr.String())),
+			Remediation: "To fix this error, confirm `npm` is installed and runnable in this directory, then re-run the command.",
+		}
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,137 @@
+package compute
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fastly/cli/pkg/common"
+)
+
+// PackageManager abstracts over the JavaScript package manager used to
+// install and resolve dependencies for a toolchain. Implementations exist
+// for npm, Yarn and pnpm so that callers don't need to special-case each
+// tool's CLI surface.
+type PackageManager interface {
+	// Name returns the name of the package manager binary.
+	Name() string
+
+	// Install installs the dependencies declared in package.json, as
+	// constrained by opts. The invocation is only traced to out at debug
+	// level when verbose is true.
+	Install(out io.Writer, verbose bool, opts InitializeOptions) error
+
+	// BinPath returns the directory containing locally installed binaries.
+	BinPath() (string, error)
+}
+
+// InitializeOptions controls how a Toolchain's Initialize and Build methods
+// install dependencies.
+type InitializeOptions struct {
+	// Production skips devDependencies unrelated to the Compute@Edge runtime.
+	Production bool
+
+	// Frozen fails the install if it would require changes to the lockfile
+	// (`npm ci`, `yarn install --frozen-lockfile`, `pnpm install --frozen-lockfile`).
+	Frozen bool
+}
+
+// packageManagers maps the supported package manager names to constructors,
+// used both for explicit `package_manager` overrides and lockfile detection.
+var packageManagers = map[string]func() PackageManager{
+	"npm":  func() PackageManager { return NPM{} },
+	"yarn": func() PackageManager { return Yarn{} },
+	"pnpm": func() PackageManager { return PNPM{} },
+}
+
+// lockFiles maps a lockfile name to the package manager that produces it, in
+// priority order, used to auto-detect which package manager a project uses.
+var lockFiles = []struct {
+	file string
+	name string
+}{
+	{"yarn.lock", "yarn"},
+	{"pnpm-lock.yaml", "pnpm"},
+	{"package-lock.json", "npm"},
+}
+
+// DeterminePackageManager selects a PackageManager for the current
+// directory. An explicit name (sourced from the `package_manager` field
+// under `[language.assemblyscript]` in fastly.toml) takes precedence;
+// otherwise the lockfile present in $PWD is used; otherwise npm is assumed.
+func DeterminePackageManager(explicit string) (PackageManager, error) {
+	if explicit != "" {
+		ctor, ok := packageManagers[explicit]
+		if !ok {
+			return nil, fmt.Errorf("unrecognised package_manager %q (expected one of: npm, yarn, pnpm)", explicit)
+		}
+		return ctor(), nil
+	}
+
+	for _, lf := range lockFiles {
+		if common.FileExists(lf.file) {
+			return packageManagers[lf.name](), nil
+		}
+	}
+
+	return NPM{}, nil
+}
+
+// wrapExecError wraps err with the trimmed output captured from a failed
+// package manager invocation, so callers (and, ultimately,
+// errors.RemediationError) can surface what the package manager actually
+// said instead of just its exit status.
+func wrapExecError(err error, output string) error {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, output)
+}
+
+// debugf writes a debug-level trace of a package manager invocation to out
+// when verbose is true, so what's about to be shelled out is visible
+// alongside its streamed output without cluttering normal CLI output.
+func debugf(out io.Writer, verbose bool, format string, args ...interface{}) {
+	if !verbose {
+		return
+	}
+	fmt.Fprintf(out, "[debug] "+format+"\n", args...)
+}
+
+// packageJSON represents the subset of package.json fields needed to check
+// whether a dependency is declared, without shelling out to the package
+// manager.
+type packageJSON struct {
+	Dependencies     map[string]string `json:"dependencies"`
+	DevDependencies  map[string]string `json:"devDependencies"`
+	PeerDependencies map[string]string `json:"peerDependencies"`
+}
+
+// packageDependencyExists reports whether name is declared as a dependency,
+// devDependency or peerDependency in the package.json file in $PWD.
+func packageDependencyExists(name string) (bool, error) {
+	data, err := os.ReadFile("package.json")
+	if err != nil {
+		return false, fmt.Errorf("reading package.json: %w", err)
+	}
+
+	var pkg packageJSON
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return false, fmt.Errorf("parsing package.json: %w", err)
+	}
+
+	if _, ok := pkg.Dependencies[name]; ok {
+		return true, nil
+	}
+	if _, ok := pkg.DevDependencies[name]; ok {
+		return true, nil
+	}
+	if _, ok := pkg.PeerDependencies[name]; ok {
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,101 @@
+package compute
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestPackageManagerInstallFlags asserts that Install translates
+// InitializeOptions into the correct command-line flags for each supported
+// package manager, by shelling out to a fake binary that records the
+// arguments it was invoked with.
+func TestPackageManagerInstallFlags(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake package manager binaries require a POSIX shell")
+	}
+
+	for _, tt := range []struct {
+		name     string
+		pm       PackageManager
+		opts     InitializeOptions
+		wantArgs string
+	}{
+		{"npm default", NPM{}, InitializeOptions{}, "install"},
+		{"npm production", NPM{}, InitializeOptions{Production: true}, "install --production"},
+		{"npm frozen", NPM{}, InitializeOptions{Frozen: true}, "ci"},
+		{"npm production and frozen", NPM{}, InitializeOptions{Production: true, Frozen: true}, "ci --production"},
+		{"yarn default", Yarn{}, InitializeOptions{}, "install"},
+		{"yarn production and frozen", Yarn{}, InitializeOptions{Production: true, Frozen: true}, "install --production --frozen-lockfile"},
+		{"pnpm frozen", PNPM{}, InitializeOptions{Frozen: true}, "install --frozen-lockfile"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			recordPath := filepath.Join(dir, "args.txt")
+			installFakeBin(t, dir, tt.pm.Name(), recordPath)
+
+			oldPath := os.Getenv("PATH")
+			os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+			t.Cleanup(func() { os.Setenv("PATH", oldPath) })
+
+			var out bytes.Buffer
+			if err := tt.pm.Install(&out, false, tt.opts); err != nil {
+				t.Fatalf("Install returned an unexpected error: %v", err)
+			}
+
+			got, err := os.ReadFile(recordPath)
+			if err != nil {
+				t.Fatalf("reading recorded args: %v", err)
+			}
+			if strings.TrimSpace(string(got)) != tt.wantArgs {
+				t.Errorf("got args %q, want %q", strings.TrimSpace(string(got)), tt.wantArgs)
+			}
+		})
+	}
+}
+
+// TestPackageManagerInstallDebugLogging asserts that Install only traces its
+// invocation to out when verbose is true, so normal `fastly compute`
+// output isn't cluttered with it.
+func TestPackageManagerInstallDebugLogging(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake package manager binaries require a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	installFakeBin(t, dir, "npm", filepath.Join(dir, "args.txt"))
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+	t.Cleanup(func() { os.Setenv("PATH", oldPath) })
+
+	var quiet bytes.Buffer
+	if err := (NPM{}).Install(&quiet, false, InitializeOptions{}); err != nil {
+		t.Fatalf("Install returned an unexpected error: %v", err)
+	}
+	if strings.Contains(quiet.String(), "[debug]") {
+		t.Errorf("expected no debug output when verbose is false, got %q", quiet.String())
+	}
+
+	var verbose bytes.Buffer
+	if err := (NPM{}).Install(&verbose, true, InitializeOptions{}); err != nil {
+		t.Fatalf("Install returned an unexpected error: %v", err)
+	}
+	if !strings.Contains(verbose.String(), "[debug]") {
+		t.Errorf("expected debug output when verbose is true, got %q", verbose.String())
+	}
+}
+
+// installFakeBin writes an executable shell script named name into dir that
+// records its arguments to recordPath and exits successfully.
+func installFakeBin(t *testing.T, dir, name, recordPath string) {
+	t.Helper()
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %q\n", recordPath)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+		t.Fatalf("writing fake %s: %v", name, err)
+	}
+}
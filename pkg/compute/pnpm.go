@@ -0,0 +1,56 @@
+package compute
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/errors"
+)
+
+// PNPM implements PackageManager for pnpm.
+type PNPM struct{}
+
+// Name implements the PackageManager interface.
+func (p PNPM) Name() string { return "pnpm" }
+
+// Install implements the PackageManager interface.
+func (p PNPM) Install(out io.Writer, verbose bool, opts InitializeOptions) error {
+	args := []string{"install"}
+	if opts.Production {
+		args = append(args, "--production")
+	}
+	if opts.Frozen {
+		args = append(args, "--frozen-lockfile")
+	}
+
+	debugf(out, verbose, "running `pnpm %s`", strings.Join(args, " "))
+
+	var buf bytes.Buffer
+	cmd := common.NewStreamingExec("pnpm", args, []string{}, false, io.MultiWriter(out, &buf))
+	if err := cmd.Exec(); err != nil {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("pnpm install failed: %w", wrapExecError(err, buf.String())),
+			Remediation: "To fix this error, check the pnpm output above for the underlying cause (e.g. a network failure, registry permissions, or a broken package.json), then re-run the command.",
+		}
+	}
+	return nil
+}
+
+// BinPath implements the PackageManager interface.
+func (p PNPM) BinPath() (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("pnpm", "bin")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.RemediationError{
+			Inner:       fmt.Errorf("error getting pnpm bin path: %w", wrapExecError(err, stderr.String())),
+			Remediation: "To fix this error, confirm `pnpm` is installed and runnable in this directory, then re-run the command.",
+		}
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,557 @@
+package compute
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/errors"
+)
+
+// nodeDistIndexURL is the nodejs.org index of every published release,
+// newest first, used to resolve a semver range (e.g. "^16") to a concrete
+// version before downloading it.
+const nodeDistIndexURL = "https://nodejs.org/dist/index.json"
+
+// ToolchainConstraints describes the Node/asc version ranges a project
+// requires, as configured in fastly.toml or a .nvmrc file.
+type ToolchainConstraints struct {
+	Node string
+	Asc  string
+}
+
+// manifestLanguageAssemblyScript mirrors the `[language.assemblyscript]`
+// table in fastly.toml.
+type manifestLanguageAssemblyScript struct {
+	Node string `toml:"node"`
+	Asc  string `toml:"asc"`
+}
+
+type partialManifest struct {
+	Language struct {
+		AssemblyScript manifestLanguageAssemblyScript `toml:"assemblyscript"`
+	} `toml:"language"`
+}
+
+// ReadToolchainConstraints reads the Node/asc version constraints for the
+// current project, preferring the `[language.assemblyscript]` table in
+// fastly.toml and falling back to a .nvmrc file in the project root for the
+// Node constraint.
+func ReadToolchainConstraints() (ToolchainConstraints, error) {
+	var constraints ToolchainConstraints
+
+	if common.FileExists("fastly.toml") {
+		var m partialManifest
+		if _, err := toml.DecodeFile("fastly.toml", &m); err != nil {
+			return constraints, fmt.Errorf("parsing fastly.toml: %w", err)
+		}
+		constraints.Node = m.Language.AssemblyScript.Node
+		constraints.Asc = m.Language.AssemblyScript.Asc
+	}
+
+	if constraints.Node == "" && common.FileExists(".nvmrc") {
+		data, err := os.ReadFile(".nvmrc")
+		if err != nil {
+			return constraints, fmt.Errorf("reading .nvmrc: %w", err)
+		}
+		constraints.Node = strings.TrimSpace(string(data))
+	}
+
+	return constraints, nil
+}
+
+// ToolchainCacheDir returns the directory the CLI caches provisioned Node
+// distributions under, creating it if it doesn't already exist.
+func ToolchainCacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting user home directory: %w", err)
+	}
+	dir := filepath.Join(home, ".fastly", "toolchains", "node")
+	if err := common.MakeDirectoryIfNotExists(dir); err != nil {
+		return "", fmt.Errorf("making toolchain cache directory: %w", err)
+	}
+	return dir, nil
+}
+
+var versionRegexp = regexp.MustCompile(`\d+\.\d+\.\d+`)
+
+// installedVersion shells out to `<bin> <args...>` and extracts a semver
+// version number from its output.
+func installedVersion(bin string, args ...string) (*semver.Version, error) {
+	out, err := exec.Command(bin, args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	match := versionRegexp.FindString(string(out))
+	if match == "" {
+		return nil, fmt.Errorf("could not parse a version number from %q", string(out))
+	}
+	return semver.NewVersion(match)
+}
+
+func satisfies(version *semver.Version, constraint string) (bool, error) {
+	if constraint == "" {
+		return true, nil
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false, fmt.Errorf("parsing version constraint %q: %w", constraint, err)
+	}
+	return c.Check(version), nil
+}
+
+// CheckAscVersion verifies that the asc compiler at ascPath satisfies
+// constraints.Asc (the `asc` field under `[language.assemblyscript]` in
+// fastly.toml), returning a RemediationError if it doesn't. It's a no-op
+// when no asc constraint is configured.
+func CheckAscVersion(ascPath string, constraints ToolchainConstraints) error {
+	if constraints.Asc == "" {
+		return nil
+	}
+
+	version, err := installedVersion(ascPath, "--version")
+	if err != nil {
+		return fmt.Errorf("checking asc version: %w", err)
+	}
+
+	ok, err := satisfies(version, constraints.Asc)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("installed asc version %s does not satisfy %q", version, constraints.Asc),
+			Remediation: fmt.Sprintf("To fix this error, install a version of `assemblyscript` matching %q, e.g.:\n\n\t$ npm install --save-dev assemblyscript@%q", constraints.Asc, constraints.Asc),
+		}
+	}
+	return nil
+}
+
+// resolveNodeVersion resolves constraint (an exact version or a semver range
+// such as "^16" or "~16.14") to the highest published nodejs.org release
+// that satisfies it, so auto-provisioning works for the ranges users
+// actually write in fastly.toml and not just exact pins.
+func resolveNodeVersion(constraint string) (*semver.Version, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing version constraint %q: %w", constraint, err)
+	}
+
+	releases, err := fetchNodeReleases()
+	if err != nil {
+		return nil, err
+	}
+
+	var best *semver.Version
+	for _, v := range releases {
+		if !c.Check(v) {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best = v
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no published Node.js release satisfies %q", constraint)
+	}
+	return best, nil
+}
+
+// findCachedNodeVersion returns the highest version already provisioned
+// under cacheDir that satisfies constraint, or nil if none is cached. It
+// never touches the network, so a constraint already satisfied by a prior
+// `fastly compute toolchain install` resolves without one.
+func findCachedNodeVersion(cacheDir, constraint string) (*semver.Version, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing version constraint %q: %w", constraint, err)
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading toolchain cache directory: %w", err)
+	}
+
+	var best *semver.Version
+	for _, e := range entries {
+		if !e.IsDir() || !common.FileExists(filepath.Join(cacheDir, e.Name(), "bin")) {
+			continue
+		}
+		v, err := semver.NewVersion(strings.TrimPrefix(e.Name(), "v"))
+		if err != nil {
+			continue
+		}
+		if !c.Check(v) {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best = v
+		}
+	}
+	return best, nil
+}
+
+// fetchNodeReleases fetches and parses the nodejs.org release index.
+func fetchNodeReleases() ([]*semver.Version, error) {
+	resp, err := http.Get(nodeDistIndexURL) // nolint:gosec,noctx
+	if err != nil {
+		return nil, fmt.Errorf("fetching Node.js release index: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching Node.js release index: unexpected status %s", resp.Status)
+	}
+
+	var entries []struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("parsing Node.js release index: %w", err)
+	}
+
+	versions := make([]*semver.Version, 0, len(entries))
+	for _, e := range entries {
+		v, err := semver.NewVersion(e.Version)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// ResolveToolchain ensures a Node.js distribution satisfying constraints.Node
+// is available, provisioning one into the CLI's toolchain cache if
+// necessary, and returns the `bin` directory that should be prepended to
+// $PATH in order to use it. An empty string is returned when no constraint
+// is configured or the installed Node.js already satisfies it. If offline is
+// true, no download is attempted and a RemediationError is returned instead
+// when the requirement isn't already met locally.
+func ResolveToolchain(out io.Writer, constraints ToolchainConstraints, offline bool) (string, error) {
+	if constraints.Node == "" {
+		return "", nil
+	}
+
+	if version, err := installedVersion("node", "--version"); err == nil {
+		ok, err := satisfies(version, constraints.Node)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return "", nil
+		}
+	}
+
+	cacheDir, err := ToolchainCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	// Check the toolchain cache for a version already provisioned on a
+	// previous run before resolving the constraint over the network: a
+	// build shouldn't need network access just to reuse a toolchain it
+	// already downloaded.
+	cached, err := findCachedNodeVersion(cacheDir, constraints.Node)
+	if err != nil {
+		return "", err
+	}
+	if cached != nil {
+		return filepath.Join(cacheDir, fmt.Sprintf("v%s", cached), "bin"), nil
+	}
+
+	if offline {
+		return "", errors.RemediationError{
+			Inner:       fmt.Errorf("no installed or cached Node.js version satisfies %q", constraints.Node),
+			Remediation: "To fix this error, install a matching Node.js version, or run `fastly compute toolchain install` without --offline.",
+		}
+	}
+
+	pinned, err := resolveNodeVersion(constraints.Node)
+	if err != nil {
+		return "", errors.RemediationError{
+			Inner:       fmt.Errorf("cannot auto-provision Node.js for constraint %q: %w", constraints.Node, err),
+			Remediation: "To fix this error, pin a Node.js version or range (e.g. \"16.14.0\" or \"^16\") under [language.assemblyscript] in fastly.toml that matches a published release at https://nodejs.org/dist/, or install a matching version manually.",
+		}
+	}
+
+	dir := filepath.Join(cacheDir, fmt.Sprintf("v%s", pinned))
+	binDir := filepath.Join(dir, "bin")
+	if common.FileExists(binDir) {
+		return binDir, nil
+	}
+
+	fmt.Fprintf(out, "Provisioning Node.js v%s...\n", pinned)
+
+	if err := downloadNodeDistribution(pinned.String(), dir); err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(out, "Installed Node.js v%s to %s\n", pinned, dir)
+
+	return binDir, nil
+}
+
+// InstallToolchain resolves and provisions the project's configured Node.js
+// toolchain ahead of time, without requiring a build. It backs the `fastly
+// compute toolchain install` command.
+func InstallToolchain(out io.Writer) error {
+	constraints, err := ReadToolchainConstraints()
+	if err != nil {
+		return err
+	}
+	if constraints.Node == "" {
+		fmt.Fprintf(out, "No Node.js version constraint configured; nothing to install.\n")
+		return nil
+	}
+	_, err = ResolveToolchain(out, constraints, false)
+	return err
+}
+
+// downloadNodeDistribution downloads and unpacks the Node.js distribution
+// for the current OS/arch into dir, verifying it against the published
+// SHASUMS256.txt checksum before extracting it.
+func downloadNodeDistribution(version, dir string) error {
+	archiveName, err := nodeArchiveName(version)
+	if err != nil {
+		return err
+	}
+
+	archiveFile := archiveName + ".tar.gz"
+	baseURL := fmt.Sprintf("https://nodejs.org/dist/v%s/", version)
+
+	archivePath, err := downloadToTempFile(baseURL + archiveFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archivePath) // nolint:errcheck
+
+	if err := verifyNodeChecksum(archivePath, baseURL+"SHASUMS256.txt", archiveFile); err != nil {
+		return fmt.Errorf("verifying %s: %w", archiveFile, err)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("opening downloaded node distribution: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("decompressing node distribution: %w", err)
+	}
+	defer gz.Close()
+
+	if err := common.MakeDirectoryIfNotExists(dir); err != nil {
+		return err
+	}
+
+	prefix := archiveName + "/"
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading node distribution archive: %w", err)
+		}
+
+		name := strings.TrimPrefix(hdr.Name, prefix)
+		if name == "" || name == hdr.Name {
+			continue
+		}
+		target := filepath.Join(dir, name)
+		if !pathIsWithinDir(dir, target) {
+			return fmt.Errorf("node distribution archive entry %q escapes %s", hdr.Name, dir)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := common.MakeDirectoryIfNotExists(target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := common.MakeDirectoryIfNotExists(filepath.Dir(target)); err != nil {
+				return err
+			}
+			if err := writeTarFile(target, hdr, tr); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// The official distributions symlink bin/npm and bin/npx into
+			// lib/node_modules/npm/bin/..., so these must be recreated for
+			// the provisioned toolchain to have a usable npm on $PATH.
+			if filepath.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("node distribution archive entry %q has an absolute symlink target %q", hdr.Name, hdr.Linkname)
+			}
+			resolved := filepath.Join(filepath.Dir(target), hdr.Linkname)
+			if !pathIsWithinDir(dir, resolved) {
+				return fmt.Errorf("node distribution archive entry %q has a symlink target %q escaping %s", hdr.Name, hdr.Linkname, dir)
+			}
+			if err := common.MakeDirectoryIfNotExists(filepath.Dir(target)); err != nil {
+				return err
+			}
+			if err := os.RemoveAll(target); err != nil {
+				return fmt.Errorf("replacing %s: %w", target, err)
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return fmt.Errorf("creating symlink %s: %w", target, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// pathIsWithinDir reports whether target resolves to a location inside dir,
+// guarding tar/zip-slip style archive entries (e.g. containing `../`
+// components) from writing outside the intended extraction directory.
+func pathIsWithinDir(dir, target string) bool {
+	rel, err := filepath.Rel(dir, target)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// downloadToTempFile downloads url's body into a temporary file and returns
+// its path, so the download can be checksum-verified before anything reads
+// it as a trusted archive.
+func downloadToTempFile(url string) (string, error) {
+	resp, err := http.Get(url) // nolint:gosec,noctx
+	if err != nil {
+		return "", fmt.Errorf("downloading %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading %s: unexpected status %s", url, resp.Status)
+	}
+
+	f, err := os.CreateTemp("", "fastly-node-dist-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("creating temp file for %s: %w", url, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		os.Remove(f.Name()) // nolint:errcheck
+		return "", fmt.Errorf("downloading %s: %w", url, err)
+	}
+
+	return f.Name(), nil
+}
+
+// verifyNodeChecksum downloads shasumsURL (nodejs.org's SHASUMS256.txt for a
+// given release) and confirms the file at path hashes to the value listed
+// there for archiveFile.
+func verifyNodeChecksum(path, shasumsURL, archiveFile string) error {
+	resp, err := http.Get(shasumsURL) // nolint:gosec,noctx
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", shasumsURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s: unexpected status %s", shasumsURL, resp.Status)
+	}
+
+	var expected string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == archiveFile {
+			expected = fields[0]
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading %s: %w", shasumsURL, err)
+	}
+	if expected == "" {
+		return fmt.Errorf("no checksum listed for %s in %s", archiveFile, shasumsURL)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("hashing %s: %w", path, err)
+	}
+
+	if got := hex.EncodeToString(h.Sum(nil)); got != expected {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", archiveFile, got, expected)
+	}
+	return nil
+}
+
+func writeTarFile(target string, hdr *tar.Header, r io.Reader) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", target, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil { // nolint:gosec
+		return fmt.Errorf("writing %s: %w", target, err)
+	}
+	return nil
+}
+
+// nodeArchiveName returns the nodejs.org distribution archive name for the
+// current OS/arch, without its .tar.gz extension.
+func nodeArchiveName(version string) (string, error) {
+	var osName string
+	switch runtime.GOOS {
+	case "linux":
+		osName = "linux"
+	case "darwin":
+		osName = "darwin"
+	default:
+		return "", fmt.Errorf("unsupported OS for Node.js auto-provisioning: %s", runtime.GOOS)
+	}
+
+	var archName string
+	switch runtime.GOARCH {
+	case "amd64":
+		archName = "x64"
+	case "arm64":
+		archName = "arm64"
+	default:
+		return "", fmt.Errorf("unsupported architecture for Node.js auto-provisioning: %s", runtime.GOARCH)
+	}
+
+	return fmt.Sprintf("node-v%s-%s-%s", version, osName, archName), nil
+}
+
+// prependPath prepends dir to the current process's $PATH environment
+// variable, so that subsequently exec'd child processes (npm, asc, etc.)
+// resolve against the provisioned toolchain first.
+func prependPath(dir string) {
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH")) // nolint:errcheck
+}
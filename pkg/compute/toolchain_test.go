@@ -0,0 +1,79 @@
+package compute
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPathIsWithinDir guards the tar-slip protection in
+// downloadNodeDistribution: archive entries containing `../` components
+// must never resolve outside the extraction directory.
+func TestPathIsWithinDir(t *testing.T) {
+	dir := filepath.Join(string(os.PathSeparator), "tmp", "toolchain-cache", "v18.0.0")
+
+	for _, tt := range []struct {
+		name string
+		rel  string
+		want bool
+	}{
+		{"regular file", "bin/node", true},
+		{"nested file", "lib/node_modules/npm/bin/npm", true},
+		{"escapes one level", "../outside", false},
+		{"escapes several levels", "../../../../etc/passwd", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			target := filepath.Join(dir, tt.rel)
+			if got := pathIsWithinDir(dir, target); got != tt.want {
+				t.Errorf("pathIsWithinDir(%q, %q) = %v, want %v", dir, target, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestVerifyNodeChecksum asserts that verifyNodeChecksum accepts a download
+// matching its published SHASUMS256.txt entry and rejects both a checksum
+// mismatch and a missing entry.
+func TestVerifyNodeChecksum(t *testing.T) {
+	const archiveFile = "node-v18.0.0-linux-x64.tar.gz"
+
+	content := []byte("pretend node distribution contents")
+	sum := sha256.Sum256(content)
+	hexSum := hex.EncodeToString(sum[:])
+
+	archivePath := filepath.Join(t.TempDir(), archiveFile)
+	if err := os.WriteFile(archivePath, content, 0o644); err != nil {
+		t.Fatalf("writing fake archive: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		shasums string
+		wantErr bool
+	}{
+		{"matching checksum", fmt.Sprintf("%s  %s\n", hexSum, archiveFile), false},
+		{"checksum mismatch", fmt.Sprintf("%s  %s\n", strings.Repeat("0", 64), archiveFile), true},
+		{"no entry for archive", fmt.Sprintf("%s  node-v18.0.0-darwin-x64.tar.gz\n", hexSum), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tt.shasums))
+			}))
+			defer srv.Close()
+
+			err := verifyNodeChecksum(archivePath, srv.URL, archiveFile)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}